@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"path"
+	"sort"
+	"strconv"
+)
+
+// pageSize is how many posts each paginated index page ("/?page=N") holds.
+const pageSize = 10
+
+// paginate splits pages into chunks of size, oldest-chunking-aside: pages is
+// assumed to already be in the order callers want displayed.
+func paginate(pages []*Page, size int) [][]*Page {
+	if size <= 0 || len(pages) == 0 {
+		return [][]*Page{pages}
+	}
+	var chunks [][]*Page
+	for i := 0; i < len(pages); i += size {
+		end := i + size
+		if end > len(pages) {
+			end = len(pages)
+		}
+		chunks = append(chunks, pages[i:end])
+	}
+	return chunks
+}
+
+// renderIndexPages renders one index.html execution per page of results, so
+// serving "/?page=N" is a map lookup rather than a per-request render. Each
+// page's ETag/Last-Modified is derived from its own chunk, so editing an
+// older post only invalidates the index pages that actually changed.
+func renderIndexPages(store Store, templatesDir string, pages []*Page) (map[int]cached, error) {
+	tmplSrc, err := store.ReadFile(path.Join(templatesDir, "index.html"))
+	if err != nil {
+		return nil, err
+	}
+	tmpl, err := template.New("index.html").Parse(string(tmplSrc))
+	if err != nil {
+		return nil, err
+	}
+
+	chunks := paginate(pages, pageSize)
+	result := make(map[int]cached, len(chunks))
+	for i, chunk := range chunks {
+		n := i + 1
+		wr := &bytes.Buffer{}
+		data := map[string]interface{}{
+			"Pages":      chunk,
+			"Page":       n,
+			"TotalPages": len(chunks),
+		}
+		if err := tmpl.Execute(wr, data); err != nil {
+			return nil, err
+		}
+		result[n] = newCached(wr.Bytes(), latest(chunk))
+	}
+	return result, nil
+}
+
+// tagGroups maps each tag to the pages carrying it, in the order pages was
+// given.
+func tagGroups(pages []*Page) map[string][]*Page {
+	groups := make(map[string][]*Page)
+	for _, p := range pages {
+		for _, t := range p.Tags {
+			groups[t] = append(groups[t], p)
+		}
+	}
+	return groups
+}
+
+// yearGroups maps each publication year (as "2006") to the pages published
+// in it, in the order pages was given.
+func yearGroups(pages []*Page) map[string][]*Page {
+	groups := make(map[string][]*Page)
+	for _, p := range pages {
+		year := strconv.Itoa(p.Date.Year())
+		groups[year] = append(groups[year], p)
+	}
+	return groups
+}
+
+// renderTags renders the "/tags/" index listing every known tag.
+func renderTags(store Store, templatesDir string, groups map[string][]*Page) ([]byte, error) {
+	tmplSrc, err := store.ReadFile(path.Join(templatesDir, "tags.html"))
+	if err != nil {
+		return nil, err
+	}
+	tmpl, err := template.New("tags.html").Parse(string(tmplSrc))
+	if err != nil {
+		return nil, err
+	}
+
+	tags := make([]string, 0, len(groups))
+	for tag := range groups {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+
+	wr := &bytes.Buffer{}
+	data := map[string]interface{}{"Tags": tags, "Groups": groups}
+	if err := tmpl.Execute(wr, data); err != nil {
+		return nil, err
+	}
+	return wr.Bytes(), nil
+}
+
+// renderTagPages renders one tag.html execution per tag, keyed by tag name.
+func renderTagPages(store Store, templatesDir string, groups map[string][]*Page) (map[string][]byte, error) {
+	tmplSrc, err := store.ReadFile(path.Join(templatesDir, "tag.html"))
+	if err != nil {
+		return nil, err
+	}
+	tmpl, err := template.New("tag.html").Parse(string(tmplSrc))
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string][]byte, len(groups))
+	for tag, pages := range groups {
+		wr := &bytes.Buffer{}
+		data := map[string]interface{}{"Tag": tag, "Pages": pages}
+		if err := tmpl.Execute(wr, data); err != nil {
+			return nil, fmt.Errorf("execute tag %q: %w", tag, err)
+		}
+		result[tag] = wr.Bytes()
+	}
+	return result, nil
+}
+
+// renderArchivePages renders one archive.html execution per year, keyed by
+// year (e.g. "2024").
+func renderArchivePages(store Store, templatesDir string, groups map[string][]*Page) (map[string][]byte, error) {
+	tmplSrc, err := store.ReadFile(path.Join(templatesDir, "archive.html"))
+	if err != nil {
+		return nil, err
+	}
+	tmpl, err := template.New("archive.html").Parse(string(tmplSrc))
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string][]byte, len(groups))
+	for year, pages := range groups {
+		wr := &bytes.Buffer{}
+		data := map[string]interface{}{"Year": year, "Pages": pages}
+		if err := tmpl.Execute(wr, data); err != nil {
+			return nil, fmt.Errorf("execute archive %q: %w", year, err)
+		}
+		result[year] = wr.Bytes()
+	}
+	return result, nil
+}
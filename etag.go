@@ -0,0 +1,68 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// sha256ETag returns a strong ETag (a quoted hex SHA-256 digest) for body.
+func sha256ETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+// cached pairs rendered content with the ETag and Last-Modified used to
+// answer conditional requests without re-rendering or re-hashing.
+type cached struct {
+	body    []byte
+	etag    string
+	modTime time.Time
+}
+
+func newCached(body []byte, modTime time.Time) cached {
+	return cached{body: body, etag: sha256ETag(body), modTime: modTime}
+}
+
+// serve answers a request for c, honoring If-None-Match / If-Modified-Since
+// with a bodyless 304, and otherwise writes the full body. ETag,
+// Last-Modified and Cache-Control are set on every response so caches can
+// pick them up regardless of the outcome. contentType and cacheControl are
+// skipped when empty.
+func (c cached) serve(w http.ResponseWriter, r *http.Request, contentType, cacheControl string) {
+	if contentType != "" {
+		w.Header().Set("Content-Type", contentType)
+	}
+	w.Header().Set("ETag", c.etag)
+	if !c.modTime.IsZero() {
+		w.Header().Set("Last-Modified", c.modTime.UTC().Format(http.TimeFormat))
+	}
+	if cacheControl != "" {
+		w.Header().Set("Cache-Control", cacheControl)
+	}
+
+	if notModified(r, c.etag, c.modTime) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Write(c.body)
+}
+
+func notModified(r *http.Request, etag string, modTime time.Time) bool {
+	if match := r.Header.Get("If-None-Match"); match != "" {
+		for _, candidate := range strings.Split(match, ",") {
+			if strings.TrimSpace(candidate) == etag {
+				return true
+			}
+		}
+		return false
+	}
+	if since := r.Header.Get("If-Modified-Since"); since != "" && !modTime.IsZero() {
+		if t, err := http.ParseTime(since); err == nil && !modTime.After(t) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"time"
+)
+
+func scheme(tls bool) string {
+	if tls {
+		return "https://"
+	}
+	return "http://"
+}
+
+type sitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+type urlset struct {
+	XMLName xml.Name     `xml:"urlset"`
+	Xmlns   string       `xml:"xmlns,attr"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+// renderSitemap builds a sitemap.xml (per sitemaps.org) listing every page
+// URL with a <lastmod> derived from Page.Date.
+func renderSitemap(hostname string, tls bool, pages []*Page) ([]byte, error) {
+	host := scheme(tls) + hostname
+	set := urlset{
+		Xmlns: "http://www.sitemaps.org/schemas/sitemap/0.9",
+		URLs:  []sitemapURL{{Loc: host + "/"}},
+	}
+	for _, p := range pages {
+		set.URLs = append(set.URLs, sitemapURL{
+			Loc:     host + p.URL,
+			LastMod: p.Date.Format("2006-01-02"),
+		})
+	}
+
+	out, err := xml.MarshalIndent(set, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), out...), nil
+}
+
+type jsonFeed struct {
+	Version     string         `json:"version"`
+	Title       string         `json:"title"`
+	HomePageURL string         `json:"home_page_url"`
+	FeedURL     string         `json:"feed_url"`
+	Description string         `json:"description,omitempty"`
+	Items       []jsonFeedItem `json:"items"`
+}
+
+type jsonFeedItem struct {
+	ID            string `json:"id"`
+	URL           string `json:"url"`
+	Title         string `json:"title"`
+	ContentHTML   string `json:"content_html"`
+	DatePublished string `json:"date_published"`
+}
+
+// renderJSONFeed builds a JSON Feed 1.1 document (https://jsonfeed.org/version/1.1)
+// from the same pages slice used for the RSS feed.
+func renderJSONFeed(hostname string, tls bool, pages []*Page) ([]byte, error) {
+	host := scheme(tls) + hostname
+	feed := jsonFeed{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       "satran's blog",
+		HomePageURL: host,
+		FeedURL:     host + "/feed.json",
+		Description: "Some of my random thoughts, mostly about technology",
+	}
+	for i := len(pages) - 1; i >= 0; i-- {
+		p := pages[i]
+		feed.Items = append(feed.Items, jsonFeedItem{
+			ID:            host + p.URL,
+			URL:           host + p.URL,
+			Title:         p.Title,
+			ContentHTML:   p.Markdown,
+			DatePublished: p.Date.Format(time.RFC3339),
+		})
+	}
+	return json.MarshalIndent(feed, "", "  ")
+}
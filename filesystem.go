@@ -3,9 +3,9 @@ package main
 import (
 	"bytes"
 	"io"
-	"io/ioutil"
 	"net/http"
 	"os"
+	"path"
 	"strings"
 	"sync"
 )
@@ -13,61 +13,93 @@ import (
 // FileSystem custom file system handler
 type FileSystem struct {
 	sync.Mutex
-	fs    http.FileSystem
+	store Store
+	dir   string
 	cache map[string]*File
 }
 
-func newFileSystem(dir string) *FileSystem {
+func newFileSystem(store Store, dir string) *FileSystem {
 	return &FileSystem{
-		fs:    http.Dir(dir),
+		store: store,
+		dir:   dir,
 		cache: make(map[string]*File),
 	}
 }
 
 // Open opens file
-func (fs FileSystem) Open(path string) (http.File, error) {
+func (fs *FileSystem) Open(name string) (http.File, error) {
 	fs.Lock()
 	defer fs.Unlock()
-	f, ok := fs.cache[path]
+	f, ok := fs.cache[name]
 	if ok {
 		// Make sure the file can be read from first byte, otherwise images will not be rendered every other try
 		_, _ = f.Seek(0, io.SeekStart)
 		return f, nil
 	}
-	f, err := newFile(path, fs.fs)
+	f, err := newFile(fs.store, path.Join(fs.dir, name))
 	if err != nil {
 		return nil, err
 	}
-	fs.cache[path] = f
+	fs.cache[name] = f
 	return f, nil
 }
 
+// ETag returns the strong ETag of the named file, opening (and caching) it
+// if necessary.
+func (fs *FileSystem) ETag(name string) (string, bool) {
+	f, err := fs.Open(name)
+	if err != nil {
+		return "", false
+	}
+	cf, ok := f.(*File)
+	if !ok {
+		return "", false
+	}
+	return cf.ETag(), true
+}
+
 type File struct {
 	*bytes.Reader
 	stat  os.FileInfo
 	files []os.FileInfo
+	etag  string
 }
 
-func newFile(path string, fs http.FileSystem) (*File, error) {
-	f, err := fs.Open(path)
+func newFile(store Store, name string) (*File, error) {
+	f, err := store.Open(name)
 	if err != nil {
 		return nil, err
 	}
+	defer f.Close()
 
-	var files []os.FileInfo
 	s, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	var files []os.FileInfo
 	if s.IsDir() {
-		index := strings.TrimSuffix(path, "/") + "/index.html"
-		if _, err := fs.Open(index); err != nil {
+		index := strings.TrimSuffix(name, "/") + "/index.html"
+		idx, err := store.Open(index)
+		if err != nil {
 			return nil, err
 		}
-		files, err = f.Readdir(-1)
+		idx.Close()
+
+		entries, err := store.ReadDir(name)
 		if err != nil {
 			return nil, err
 		}
+		for _, e := range entries {
+			info, err := e.Info()
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, info)
+		}
 	}
 
-	by, err := ioutil.ReadAll(f)
+	by, err := io.ReadAll(f)
 	if err != nil {
 		return nil, err
 	}
@@ -76,9 +108,16 @@ func newFile(path string, fs http.FileSystem) (*File, error) {
 		Reader: bytes.NewReader(by),
 		stat:   s,
 		files:  files,
+		etag:   sha256ETag(by),
 	}, nil
 }
 
+// ETag returns f's strong ETag, computed from its content when it was
+// opened.
+func (f *File) ETag() string {
+	return f.etag
+}
+
 func (f *File) Close() error {
 	return nil
 }
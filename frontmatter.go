@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// frontMatter recognises a "---"-fenced YAML or "+++"-fenced TOML metadata
+// block at the very top of a page and decodes it into fields. ok is false
+// when no fence is present, in which case callers fall back to the legacy
+// two-line header. body is the content with the fence (and the blank line
+// after it, if any) removed.
+func frontMatter(content []byte) (fields map[string]interface{}, body []byte, ok bool, err error) {
+	start, sep := fenceFor(content)
+	if sep == nil {
+		return nil, content, false, nil
+	}
+
+	end := bytes.Index(content[start:], sep)
+	if end < 0 {
+		return nil, content, false, fmt.Errorf("unterminated front matter")
+	}
+
+	raw := content[start : start+end]
+	rest := bytes.TrimPrefix(content[start+end+len(sep):], []byte("\n"))
+
+	fields = map[string]interface{}{}
+	switch string(sep) {
+	case "\n---":
+		err = yaml.Unmarshal(raw, &fields)
+	case "\n+++":
+		err = toml.Unmarshal(raw, &fields)
+	}
+	if err != nil {
+		return nil, content, false, fmt.Errorf("decode front matter: %w", err)
+	}
+	return fields, rest, true, nil
+}
+
+func fenceFor(content []byte) (start int, sep []byte) {
+	switch {
+	case bytes.HasPrefix(content, []byte("---\n")):
+		return len("---\n"), []byte("\n---")
+	case bytes.HasPrefix(content, []byte("+++\n")):
+		return len("+++\n"), []byte("\n+++")
+	default:
+		return 0, nil
+	}
+}
+
+// applyFrontMatter copies the well-known fields off fields onto p and stashes
+// everything else in p.Extra for templates to use.
+func applyFrontMatter(p *Page, fields map[string]interface{}) error {
+	p.Extra = make(map[string]interface{})
+	for k, v := range fields {
+		switch k {
+		case "title":
+			p.Title, _ = v.(string)
+		case "date":
+			d, err := parseFrontMatterDate(v)
+			if err != nil {
+				return fmt.Errorf("parse date: %w", err)
+			}
+			p.Date = d
+		case "slug":
+			p.Slug, _ = v.(string)
+		case "tags":
+			p.Tags = toStringSlice(v)
+		case "draft":
+			p.Draft, _ = v.(bool)
+		case "summary":
+			p.Summary, _ = v.(string)
+		default:
+			p.Extra[k] = v
+		}
+	}
+	return nil
+}
+
+func parseFrontMatterDate(v interface{}) (time.Time, error) {
+	switch d := v.(type) {
+	case time.Time:
+		return d, nil
+	case string:
+		if t, err := time.Parse("02/01/2006", d); err == nil {
+			return t, nil
+		}
+		return time.Parse(time.RFC3339, d)
+	default:
+		return time.Time{}, fmt.Errorf("unsupported date value %v (%T)", v, v)
+	}
+}
+
+func toStringSlice(v interface{}) []string {
+	items, ok := v.([]interface{})
+	if !ok {
+		return nil
+	}
+	out := make([]string, 0, len(items))
+	for _, item := range items {
+		if s, ok := item.(string); ok {
+			out = append(out, s)
+		}
+	}
+	return out
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+	blackfriday "github.com/russross/blackfriday/v2"
+)
+
+// highlightConfig controls how fenced code blocks are rendered, following
+// the same style/inline-vs-external-CSS choice as the mpaste approach.
+type highlightConfig struct {
+	style        string
+	inlineStyles bool
+}
+
+// highlightRenderer wraps blackfriday's HTML renderer and substitutes
+// Chroma-highlighted spans for fenced code blocks (e.g. ```go ... ```),
+// falling back to blackfriday's default <pre><code> rendering for anything
+// Chroma can't tokenise.
+type highlightRenderer struct {
+	blackfriday.Renderer
+	cfg highlightConfig
+}
+
+func newHighlightRenderer(cfg highlightConfig) blackfriday.Renderer {
+	return &highlightRenderer{
+		Renderer: blackfriday.NewHTMLRenderer(blackfriday.HTMLRendererParameters{
+			Flags: blackfriday.CommonHTMLFlags,
+		}),
+		cfg: cfg,
+	}
+}
+
+func (r *highlightRenderer) RenderNode(w io.Writer, node *blackfriday.Node, entering bool) blackfriday.WalkStatus {
+	if node.Type != blackfriday.CodeBlock {
+		return r.Renderer.RenderNode(w, node, entering)
+	}
+
+	lang := ""
+	if fields := strings.Fields(string(node.CodeBlockData.Info)); len(fields) > 0 {
+		lang = fields[0]
+	}
+	if err := r.highlight(w, lang, node.Literal); err != nil {
+		return r.Renderer.RenderNode(w, node, entering)
+	}
+	return blackfriday.GoToNext
+}
+
+func (r *highlightRenderer) highlight(w io.Writer, lang string, code []byte) error {
+	lexer := lexers.Get(lang)
+	if lexer == nil {
+		lexer = lexers.Fallback
+	}
+	lexer = chroma.Coalesce(lexer)
+
+	style := styles.Get(r.cfg.style)
+	if style == nil {
+		style = styles.Fallback
+	}
+
+	iterator, err := lexer.Tokenise(nil, string(code))
+	if err != nil {
+		return fmt.Errorf("tokenise: %w", err)
+	}
+
+	formatter := chromahtml.New(chromahtml.WithClasses(!r.cfg.inlineStyles))
+	return formatter.Format(w, style, iterator)
+}
+
+// chromaCSS renders the class-based stylesheet served as chroma.css under
+// /s/, so pages using `chromahtml.WithClasses` stay cacheable.
+func chromaCSS(styleName string) ([]byte, error) {
+	style := styles.Get(styleName)
+	if style == nil {
+		style = styles.Fallback
+	}
+	formatter := chromahtml.New(chromahtml.WithClasses(true))
+	wr := &bytes.Buffer{}
+	if err := formatter.WriteCSS(wr, style); err != nil {
+		return nil, fmt.Errorf("write chroma css: %w", err)
+	}
+	return wr.Bytes(), nil
+}
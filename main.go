@@ -1,17 +1,16 @@
 package main
 
 import (
-	"bytes"
 	"encoding/json"
+	"flag"
 	"fmt"
-	"html/template"
 	"log"
 	"net/http"
 	"os"
-	"path/filepath"
 	"sort"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/feeds"
@@ -26,8 +25,24 @@ func main() {
 		Key:          os.Getenv("KEY"),
 		Site:         os.Getenv("SITE"),
 		MetricsToken: os.Getenv("METRICS_TOKEN"),
+		ChromaStyle:  os.Getenv("CHROMA_STYLE"),
+		ChromaInline: os.Getenv("CHROMA_INLINE_STYLES") == "true",
+		HubURL:       os.Getenv("HUB_URL"),
 		UseTLS:       true,
+		CacheMaxAge:  300,
 	}
+	if c.ChromaStyle == "" {
+		c.ChromaStyle = "monokai"
+	}
+	if v := os.Getenv("CACHE_MAX_AGE"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			c.CacheMaxAge = n
+		} else {
+			log.Println("warning: ignoring invalid CACHE_MAX_AGE:", v)
+		}
+	}
+	flag.BoolVar(&c.ShowDrafts, "drafts", false, "include draft posts in the index and feed")
+	flag.Parse()
 	if c.Site == "" {
 		log.Fatal("Specify site directory")
 	}
@@ -40,10 +55,10 @@ func main() {
 	if c.Cert == "" && c.Key == "" {
 		c.UseTLS = false
 	}
-	c.PagesDir = filepath.Join(c.Site, "blog")
-	c.StaticDir = filepath.Join(c.Site, "static")
-	c.AliasFile = filepath.Join(c.Site, "alias.json")
-	c.TemplatesDir = filepath.Join(c.Site, "templates")
+	c.PagesDir = "blog"
+	c.StaticDir = "static"
+	c.AliasFile = "alias.json"
+	c.TemplatesDir = "templates"
 	if err := run(c); err != nil {
 		log.Fatal(err)
 	}
@@ -60,27 +75,58 @@ type config struct {
 	AliasFile    string
 	MetricsToken string
 	UseTLS       bool
+	ShowDrafts   bool
+	ChromaStyle  string
+	ChromaInline bool
+	HubURL       string
+	CacheMaxAge  int
+}
+
+func (c config) highlight() highlightConfig {
+	return highlightConfig{style: c.ChromaStyle, inlineStyles: c.ChromaInline}
+}
+
+// cacheControl returns the Cache-Control header value served alongside
+// every cached response, controlled by CACHE_MAX_AGE.
+func (c config) cacheControl() string {
+	return fmt.Sprintf("public, max-age=%d", c.CacheMaxAge)
 }
 
 func run(c config) error {
-	pages, err := parse(c.TemplatesDir, c.PagesDir)
+	store, err := openStore(c.Site)
 	if err != nil {
-		return fmt.Errorf("parse pages: %w", err)
+		return fmt.Errorf("open site store: %w", err)
 	}
-	// for most purposes sorting it reverse stands best
-	sort.Sort(sort.Reverse(Pages(pages)))
 
-	aliases, err := getAliases(c.AliasFile)
+	initial, err := buildState(store, c)
 	if err != nil {
-		return fmt.Errorf("load aliases: %w", err)
+		return fmt.Errorf("build site state: %w", err)
 	}
+	state := &atomic.Pointer[siteState]{}
+	state.Store(initial)
+
+	// hot-reload only makes sense for a directory-backed store; an archive
+	// is a single immutable artifact.
+	if _, ok := store.(*fileStore); ok {
+		if err := watch(store, c, state); err != nil {
+			log.Println("warning: hot-reload disabled:", err)
+		}
+	}
+
+	cacheControl := c.cacheControl()
+	staticFS := newFileSystem(store, c.StaticDir)
 
 	m := http.ServeMux{}
 	analyze := analyzer()
-	m.Handle("/", analyze(handleIndex(c.TemplatesDir, pages, aliases)))
-	m.Handle("/feed.xml", analyze(handleFeed(c.Host, c.UseTLS, pages)))
-	m.Handle("/b/", analyze(handlePages(pages)))
-	m.Handle("/s/", analyze(http.StripPrefix("/s/", http.FileServer(newFileSystem(c.StaticDir)))))
+	m.Handle("/", analyze(handleIndex(state, cacheControl)))
+	m.Handle("/feed.xml", analyze(handleFeed(state, cacheControl)))
+	m.Handle("/b/", analyze(handlePages(state, cacheControl)))
+	m.Handle("/tags/", analyze(handleTags(state)))
+	m.Handle("/archive/", analyze(handleArchive(state)))
+	m.Handle("/s/chroma.css", analyze(handleChromaCSS(state)))
+	m.Handle("/s/", analyze(withETag(staticFS, cacheControl, http.StripPrefix("/s/", http.FileServer(staticFS)))))
+	m.Handle("/sitemap.xml", analyze(handleSitemap(state, cacheControl)))
+	m.Handle("/feed.json", analyze(handleJSONFeed(state, cacheControl)))
 	m.Handle("/metrics", requiresAuth(c.MetricsToken, promhttp.Handler()))
 
 	srv := &http.Server{
@@ -142,47 +188,122 @@ func analyzer() func(http.Handler) http.Handler {
 	}
 }
 
-func handleIndex(templatesDir string, pages []*Page, aliases map[string]string) http.Handler {
-	indexTmpl := template.Must(template.ParseFiles(filepath.Join(templatesDir, "index.html")))
-	wr := &bytes.Buffer{}
-	if err := indexTmpl.Execute(wr, map[string]interface{}{"Pages": pages}); err != nil {
-		panic(err)
+// siteState is everything derived from the site's pages, templates and
+// aliases. Handlers read it through an atomic.Pointer so a rebuild (see
+// watch in watcher.go) can be swapped in without ever serving a half-built
+// state, and without re-rendering on every request.
+type siteState struct {
+	aliases      map[string]string
+	indexPages   map[int]cached
+	byURL        map[string]cached
+	feed         cached
+	tagsIndex    []byte
+	tagPages     map[string][]byte
+	archivePages map[string][]byte
+	chromaCSS    []byte
+	sitemap      cached
+	jsonFeed     cached
+}
+
+func buildState(store Store, c config) (*siteState, error) {
+	pages, err := parse(store, c.TemplatesDir, c.PagesDir, c.highlight())
+	if err != nil {
+		return nil, fmt.Errorf("parse pages: %w", err)
 	}
-	index := wr.Bytes()
+	// for most purposes sorting it reverse stands best
+	sort.Sort(sort.Reverse(Pages(pages)))
 
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.URL.Path == "/" {
-			w.Write(index)
-			return
-		}
+	aliases, err := getAliases(store, c.AliasFile)
+	if err != nil {
+		return nil, fmt.Errorf("load aliases: %w", err)
+	}
 
-		alias, ok := aliases[r.URL.Path]
-		if !ok {
-			w.WriteHeader(http.StatusNotFound)
-			w.Write([]byte(http.StatusText(http.StatusNotFound)))
-			return
-		}
+	visible := visiblePages(pages, c.ShowDrafts)
 
-		if len(r.URL.RawQuery) > 0 {
-			alias += "?" + r.URL.RawQuery
+	indexPages, err := renderIndexPages(store, c.TemplatesDir, visible)
+	if err != nil {
+		return nil, fmt.Errorf("render index: %w", err)
+	}
+
+	// drafts are still reachable by direct link, just hidden from the index and feed
+	byURL := make(map[string]cached, len(pages))
+	for _, p := range pages {
+		byURL[p.URL] = cached{body: p.Content, etag: p.ETag, modTime: p.Date}
+	}
+
+	feedBytes, err := renderFeed(c.Host, c.UseTLS, visible)
+	if err != nil {
+		return nil, fmt.Errorf("render feed: %w", err)
+	}
+	feed := newCached(feedBytes, latest(visible))
+
+	tags := tagGroups(visible)
+	tagsIndex, err := renderTags(store, c.TemplatesDir, tags)
+	if err != nil {
+		return nil, fmt.Errorf("render tags index: %w", err)
+	}
+	tagPages, err := renderTagPages(store, c.TemplatesDir, tags)
+	if err != nil {
+		return nil, fmt.Errorf("render tag pages: %w", err)
+	}
+
+	archivePages, err := renderArchivePages(store, c.TemplatesDir, yearGroups(visible))
+	if err != nil {
+		return nil, fmt.Errorf("render archive pages: %w", err)
+	}
+
+	css, err := chromaCSS(c.ChromaStyle)
+	if err != nil {
+		return nil, fmt.Errorf("render chroma css: %w", err)
+	}
+
+	sitemapBytes, err := renderSitemap(c.Host, c.UseTLS, visible)
+	if err != nil {
+		return nil, fmt.Errorf("render sitemap: %w", err)
+	}
+	sitemap := newCached(sitemapBytes, latest(visible))
+
+	jfBytes, err := renderJSONFeed(c.Host, c.UseTLS, visible)
+	if err != nil {
+		return nil, fmt.Errorf("render json feed: %w", err)
+	}
+	jsonFeed := newCached(jfBytes, latest(visible))
+
+	return &siteState{
+		aliases:      aliases,
+		indexPages:   indexPages,
+		byURL:        byURL,
+		feed:         feed,
+		tagsIndex:    tagsIndex,
+		tagPages:     tagPages,
+		archivePages: archivePages,
+		chromaCSS:    css,
+		sitemap:      sitemap,
+		jsonFeed:     jsonFeed,
+	}, nil
+}
+
+func visiblePages(pages []*Page, includeDrafts bool) []*Page {
+	if includeDrafts {
+		return pages
+	}
+	visible := make([]*Page, 0, len(pages))
+	for _, p := range pages {
+		if !p.Draft {
+			visible = append(visible, p)
 		}
-		log.Printf("redirect to: %s", alias)
-		http.Redirect(w, r, alias, http.StatusTemporaryRedirect)
-		return
-	})
+	}
+	return visible
 }
 
-func handleFeed(hostname string, tls bool, pages []*Page) http.Handler {
+func renderFeed(hostname string, tls bool, pages []*Page) ([]byte, error) {
 	feed := &feeds.Feed{
 		Title:       "satran's blog",
 		Link:        &feeds.Link{Href: "https://satran.in"},
 		Description: "Some of my random thoughts, mostly about technology",
 		Author:      &feeds.Author{Name: "Satyajit Ranjeev", Email: "s@ranjeev.in"},
 	}
-	host := "https://" + hostname
-	if !tls {
-		host = "http://" + hostname
-	}
+	host := scheme(tls) + hostname
 	for i := len(pages) - 1; i >= 0; i-- {
 		feed.Items = append(feed.Items, &feeds.Item{
 			Title:       pages[i].Title,
@@ -194,41 +315,150 @@ func handleFeed(hostname string, tls bool, pages []*Page) http.Handler {
 
 	rss, err := feed.ToRss()
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
+	return []byte(rss), nil
+}
 
-	content := []byte(rss)
+func handleIndex(state *atomic.Pointer[siteState], cacheControl string) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
-		w.Write(content)
+		s := state.Load()
+		if r.URL.Path == "/" {
+			n := 1
+			if q := r.URL.Query().Get("page"); q != "" {
+				if v, err := strconv.Atoi(q); err == nil && v > 0 {
+					n = v
+				}
+			}
+			c, ok := s.indexPages[n]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				w.Write([]byte(http.StatusText(http.StatusNotFound)))
+				return
+			}
+			c.serve(w, r, "", cacheControl)
+			return
+		}
+
+		alias, ok := s.aliases[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(http.StatusText(http.StatusNotFound)))
+			return
+		}
+
+		if len(r.URL.RawQuery) > 0 {
+			alias += "?" + r.URL.RawQuery
+		}
+		log.Printf("redirect to: %s", alias)
+		http.Redirect(w, r, alias, http.StatusTemporaryRedirect)
+		return
 	})
 }
 
-func handlePages(pages []*Page) http.Handler {
-	parsed := make(map[string][]byte)
-	for _, p := range pages {
-		parsed[p.URL] = p.Content
-	}
+// handleTags serves the "/tags/" index; requests for a specific tag are
+// delegated to handleTag.
+func handleTags(state *atomic.Pointer[siteState]) http.Handler {
+	tag := handleTag(state)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Trim(strings.TrimPrefix(r.URL.Path, "/tags/"), "/") == "" {
+			w.Write(state.Load().tagsIndex)
+			return
+		}
+		tag.ServeHTTP(w, r)
+	})
+}
+
+// handleTag serves "/tags/<tag>/".
+func handleTag(state *atomic.Pointer[siteState]) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := strings.Trim(strings.TrimPrefix(r.URL.Path, "/tags/"), "/")
+		b, ok := state.Load().tagPages[name]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(http.StatusText(http.StatusNotFound)))
+			return
+		}
+		w.Write(b)
+	})
+}
+
+// handleArchive serves "/archive/<year>/".
+func handleArchive(state *atomic.Pointer[siteState]) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		a, ok := parsed[r.URL.Path]
+		year := strings.Trim(strings.TrimPrefix(r.URL.Path, "/archive/"), "/")
+		b, ok := state.Load().archivePages[year]
 		if !ok {
 			w.WriteHeader(http.StatusNotFound)
 			w.Write([]byte(http.StatusText(http.StatusNotFound)))
 			return
 		}
-		w.Write(a)
+		w.Write(b)
+	})
+}
+
+func handleChromaCSS(state *atomic.Pointer[siteState]) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/css; charset=utf-8")
+		w.Write(state.Load().chromaCSS)
+	})
+}
+
+func handleFeed(state *atomic.Pointer[siteState], cacheControl string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		state.Load().feed.serve(w, r, "application/rss+xml; charset=utf-8", cacheControl)
+	})
+}
+
+func handleSitemap(state *atomic.Pointer[siteState], cacheControl string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		state.Load().sitemap.serve(w, r, "application/xml; charset=utf-8", cacheControl)
+	})
+}
+
+func handleJSONFeed(state *atomic.Pointer[siteState], cacheControl string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		state.Load().jsonFeed.serve(w, r, "application/feed+json; charset=utf-8", cacheControl)
+	})
+}
+
+func handlePages(state *atomic.Pointer[siteState], cacheControl string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, ok := state.Load().byURL[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(http.StatusText(http.StatusNotFound)))
+			return
+		}
+		c.serve(w, r, "", cacheControl)
+	})
+}
+
+// withETag sets the ETag and Cache-Control headers for static files served
+// from fs before delegating to next, so http.FileServer's own
+// If-Modified-Since handling (via ServeContent) gains If-None-Match support
+// and a Cache-Control header for free.
+func withETag(fs *FileSystem, cacheControl string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimPrefix(r.URL.Path, "/s/")
+		if etag, ok := fs.ETag(name); ok {
+			w.Header().Set("ETag", etag)
+		}
+		if cacheControl != "" {
+			w.Header().Set("Cache-Control", cacheControl)
+		}
+		next.ServeHTTP(w, r)
 	})
 }
 
-func getAliases(filename string) (map[string]string, error) {
-	f, err := os.Open(filename)
+func getAliases(store Store, filename string) (map[string]string, error) {
+	b, err := store.ReadFile(filename)
 	if err != nil {
 		return nil, fmt.Errorf("opening alias file %q %w", filename, err)
 	}
-	defer f.Close()
 
 	var aliases map[string]string
-	if err := json.NewDecoder(f).Decode(&aliases); err != nil {
+	if err := json.Unmarshal(b, &aliases); err != nil {
 		return nil, fmt.Errorf("alias json decoding: %w", err)
 	}
 	return aliases, nil
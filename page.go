@@ -7,94 +7,160 @@ import (
 	"html/template"
 	"io"
 	"io/ioutil"
-	"os"
+	"path"
 	"path/filepath"
 	"strings"
 	"time"
 
-	"github.com/russross/blackfriday"
+	blackfriday "github.com/russross/blackfriday/v2"
 )
 
-func parse(templatesDir, dir string) ([]*Page, error) {
-	tmpl := template.Must(template.ParseFiles(filepath.Join(templatesDir, "page.html")))
-	files, err := ioutil.ReadDir(dir)
+func parse(store Store, templatesDir, dir string, hl highlightConfig) ([]*Page, error) {
+	tmplSrc, err := store.ReadFile(path.Join(templatesDir, "page.html"))
+	if err != nil {
+		return nil, fmt.Errorf("read template: %w", err)
+	}
+	tmpl, err := template.New("page.html").Parse(string(tmplSrc))
+	if err != nil {
+		return nil, fmt.Errorf("parse template: %w", err)
+	}
+
+	entries, err := store.ReadDir(dir)
 	if err != nil {
 		return nil, fmt.Errorf("read dir: %w", err)
 	}
 	var pages []*Page
-	for _, f := range files {
-		name := f.Name()
-		f, err := os.Open(filepath.Join(dir, name))
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		name := e.Name()
+		b, err := store.ReadFile(path.Join(dir, name))
 		if err != nil {
 			return nil, fmt.Errorf("open file: %w", err)
 		}
-		defer f.Close()
-		p, err := parsePage(tmpl, f.Name(), f)
+		p, err := parsePage(tmpl, name, bytes.NewReader(b), hl)
 		if err != nil {
-			return nil, fmt.Errorf("parse page %s: %w", f.Name(), err)
+			return nil, fmt.Errorf("parse page %s: %w", name, err)
 		}
 		pages = append(pages, p)
 	}
 	return pages, nil
 }
 
-func parsePage(tmpl *template.Template, name string, page io.Reader) (*Page, error) {
-	name = strings.TrimSuffix(filepath.Base(name), filepath.Ext(name))
+func parsePage(tmpl *template.Template, name string, page io.Reader, hl highlightConfig) (*Page, error) {
+	slug := strings.TrimSuffix(filepath.Base(name), filepath.Ext(name))
 	p := &Page{
-		URL: "/b/" + name,
+		URL: "/b/" + slug,
+	}
+
+	content, err := ioutil.ReadAll(page)
+	if err != nil {
+		return nil, fmt.Errorf("read page: %w", err)
+	}
+
+	fields, body, ok, err := frontMatter(content)
+	if err != nil {
+		return nil, fmt.Errorf("front matter: %w", err)
+	}
+
+	var rest []byte
+	if ok {
+		if err := applyFrontMatter(p, fields); err != nil {
+			return nil, err
+		}
+		rest = body
+	} else {
+		title, date, body, err := parseLegacyHeader(body)
+		if err != nil {
+			return nil, err
+		}
+		p.Title = title
+		p.Date = date
+		rest = body
+	}
+
+	if p.Slug != "" {
+		slug = p.Slug
+	}
+	p.URL = "/b/" + slug
+
+	// the template.HTML allows embedding HTML in the usually escaped HTML during template execution
+	markdown := template.HTML(string(blackfriday.Run(
+		rest,
+		blackfriday.WithExtensions(blackfriday.Footnotes|blackfriday.CommonExtensions),
+		blackfriday.WithRenderer(newHighlightRenderer(hl)),
+	)))
+
+	wr := &bytes.Buffer{}
+	data := map[string]interface{}{
+		"Title":   p.Title,
+		"Body":    markdown,
+		"Date":    p.Date,
+		"Slug":    p.Slug,
+		"Tags":    p.Tags,
+		"Draft":   p.Draft,
+		"Summary": p.Summary,
+		"Extra":   p.Extra,
+	}
+	if err := tmpl.Execute(wr, data); err != nil {
+		return nil, fmt.Errorf("template parsing: %w", err)
 	}
-	r := bufio.NewReader(page)
+	p.Content = wr.Bytes()
+	p.Markdown = string(markdown)
+	p.ETag = sha256ETag(p.Content)
+	return p, nil
+}
+
+// parseLegacyHeader parses the original two-line header format: the first
+// line is always the title, the second the date (02/01/2006), kept for
+// pages written before front matter support was added.
+func parseLegacyHeader(content []byte) (title string, date time.Time, body []byte, err error) {
+	r := bufio.NewReader(bytes.NewReader(content))
 	n := 0
-	var markdown template.HTML
 	for {
-		// the first two lines are use for meta data. The first line is always the title.
-		// Also the prefix is ignored, I'm assuming I will not create really long lines
-		line, _, err := r.ReadLine()
-		if err != nil && err != io.EOF {
-			return nil, fmt.Errorf("read page: %w", err)
+		line, _, lineErr := r.ReadLine()
+		if lineErr != nil && lineErr != io.EOF {
+			return "", time.Time{}, nil, fmt.Errorf("read page: %w", lineErr)
 		}
 		n++
 		if n == 1 {
-			p.Title = string(line)
+			title = string(line)
+			if lineErr == io.EOF {
+				break
+			}
 			continue
 		}
 		if n == 2 {
-			p.Date, err = time.Parse("02/01/2006", string(line))
+			date, err = time.Parse("02/01/2006", string(line))
 			if err != nil {
-				return nil, fmt.Errorf("couldn't parse date: %w", err)
+				return "", time.Time{}, nil, fmt.Errorf("couldn't parse date: %w", err)
 			}
 		}
-		if err == io.EOF {
+		if lineErr == io.EOF {
 			break
 		}
 
-		content, err := ioutil.ReadAll(r)
+		body, err = ioutil.ReadAll(r)
 		if err != nil {
-			return nil, fmt.Errorf("read content: %w", err)
+			return "", time.Time{}, nil, fmt.Errorf("read content: %w", err)
 		}
-		// the template.HTML allows embedding HTML in the usually escaped HTML during template execution
-		markdown = template.HTML(string(blackfriday.Run(content, blackfriday.WithExtensions(blackfriday.Footnotes | blackfriday.CommonExtensions))))
 		break
 	}
-	wr := &bytes.Buffer{}
-	data := map[string]interface{}{
-		"Title": p.Title,
-		"Body":  markdown,
-		"Date":  p.Date,
-	}
-	if err := tmpl.Execute(wr, data); err != nil {
-		return nil, fmt.Errorf("template parsing: %w", err)
-	}
-	p.Content = wr.Bytes()
-	p.Markdown = string(markdown)
-	return p, nil
+	return title, date, body, nil
 }
 
 type Page struct {
 	URL      string
 	Title    string
 	Date     time.Time
+	Slug     string
+	Tags     []string
+	Draft    bool
+	Summary  string
+	Extra    map[string]interface{}
 	Content  []byte
+	ETag     string
 	Markdown string
 }
 
@@ -103,3 +169,13 @@ type Pages []*Page
 func (p Pages) Len() int           { return len(p) }
 func (p Pages) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
 func (p Pages) Less(i, j int) bool { return p[i].Date.Before(p[j].Date) }
+
+// latest returns the most recent Date among pages, assuming pages is sorted
+// newest-first (as buildState keeps it). It returns the zero Time for an
+// empty slice.
+func latest(pages []*Page) time.Time {
+	if len(pages) == 0 {
+		return time.Time{}
+	}
+	return pages[0].Date
+}
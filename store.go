@@ -0,0 +1,254 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Store abstracts the blog's site root, which can be a plain directory or a
+// single zip/tar.gz archive containing the same tree (templates, blog posts,
+// static assets and alias.json). This lets an operator ship the whole site
+// as one artifact instead of mounting a directory.
+type Store interface {
+	Open(name string) (fs.File, error)
+	ReadDir(name string) ([]fs.DirEntry, error)
+	ReadFile(name string) ([]byte, error)
+}
+
+// openStore picks a Store implementation for root based on its suffix:
+// .zip and .tar.gz/.tgz are read into memory once, anything else is treated
+// as a directory on disk.
+func openStore(root string) (Store, error) {
+	switch {
+	case strings.HasSuffix(root, ".zip"):
+		return newZipStore(root)
+	case strings.HasSuffix(root, ".tar.gz") || strings.HasSuffix(root, ".tgz"):
+		return newTarGzStore(root)
+	default:
+		return newFileStore(root), nil
+	}
+}
+
+// fileStore is the default Store, backed by the OS filesystem.
+type fileStore struct {
+	root string
+}
+
+func newFileStore(root string) *fileStore {
+	return &fileStore{root: root}
+}
+
+func (s *fileStore) Open(name string) (fs.File, error) {
+	return os.Open(filepath.Join(s.root, name))
+}
+
+func (s *fileStore) ReadDir(name string) ([]fs.DirEntry, error) {
+	return os.ReadDir(filepath.Join(s.root, name))
+}
+
+func (s *fileStore) ReadFile(name string) ([]byte, error) {
+	return os.ReadFile(filepath.Join(s.root, name))
+}
+
+// memStore is an in-memory Store used by archive-backed sites: the whole
+// archive is decompressed once, on startup, into a flat name -> content map
+// and served from there for the life of the process.
+type memStore struct {
+	files map[string][]byte
+	dirs  map[string][]string // dir -> immediate child names
+}
+
+func newMemStore() *memStore {
+	return &memStore{
+		files: make(map[string][]byte),
+		dirs:  make(map[string][]string),
+	}
+}
+
+func (s *memStore) add(name string, content []byte) {
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	s.files[name] = content
+	s.addDir(path.Dir(name), path.Base(name))
+}
+
+// addDir records base as a child of dir, then walks up recording each
+// ancestor of dir as a child of its own parent in turn, so a directory that
+// contains only subdirectories (no files directly inside it) still shows up
+// in ReadDir.
+func (s *memStore) addDir(dir, base string) {
+	if dir == "." {
+		dir = ""
+	}
+	if _, ok := s.dirs[dir]; !ok {
+		s.dirs[dir] = nil
+	}
+	for _, n := range s.dirs[dir] {
+		if n == base {
+			return
+		}
+	}
+	s.dirs[dir] = append(s.dirs[dir], base)
+
+	if dir == "" {
+		return
+	}
+	s.addDir(path.Dir(dir), path.Base(dir))
+}
+
+func (s *memStore) Open(name string) (fs.File, error) {
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	if _, ok := s.dirs[name]; ok {
+		return &memFile{info: memFileInfo{name: path.Base(name), dir: true}}, nil
+	}
+	b, ok := s.files[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return &memFile{data: bytes.NewReader(b), info: memFileInfo{name: path.Base(name), size: int64(len(b))}}, nil
+}
+
+func (s *memStore) ReadDir(name string) ([]fs.DirEntry, error) {
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	children, ok := s.dirs[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+	names := append([]string(nil), children...)
+	sort.Strings(names)
+
+	entries := make([]fs.DirEntry, 0, len(names))
+	for _, n := range names {
+		full := path.Join(name, n)
+		_, isDir := s.dirs[full]
+		entries = append(entries, memDirEntry{name: n, dir: isDir})
+	}
+	return entries, nil
+}
+
+func (s *memStore) ReadFile(name string) ([]byte, error) {
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	b, ok := s.files[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "readfile", Path: name, Err: fs.ErrNotExist}
+	}
+	return b, nil
+}
+
+func newZipStore(archive string) (Store, error) {
+	r, err := zip.OpenReader(archive)
+	if err != nil {
+		return nil, fmt.Errorf("open zip store %q: %w", archive, err)
+	}
+	defer r.Close()
+
+	s := newMemStore()
+	for _, f := range r.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, fmt.Errorf("read zip entry %q: %w", f.Name, err)
+		}
+		b, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("read zip entry %q: %w", f.Name, err)
+		}
+		s.add(f.Name, b)
+	}
+	return s, nil
+}
+
+func newTarGzStore(archive string) (Store, error) {
+	f, err := os.Open(archive)
+	if err != nil {
+		return nil, fmt.Errorf("open tar.gz store %q: %w", archive, err)
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("gzip reader %q: %w", archive, err)
+	}
+	defer gz.Close()
+
+	s := newMemStore()
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read tar entry: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		b, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("read tar entry %q: %w", hdr.Name, err)
+		}
+		s.add(hdr.Name, b)
+	}
+	return s, nil
+}
+
+type memFile struct {
+	data *bytes.Reader
+	info memFileInfo
+}
+
+func (f *memFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *memFile) Close() error               { return nil }
+
+func (f *memFile) Read(b []byte) (int, error) {
+	if f.data == nil {
+		return 0, fmt.Errorf("read %q: is a directory", f.info.name)
+	}
+	return f.data.Read(b)
+}
+
+type memFileInfo struct {
+	name string
+	size int64
+	dir  bool
+}
+
+func (i memFileInfo) Name() string       { return i.name }
+func (i memFileInfo) Size() int64        { return i.size }
+func (i memFileInfo) Mode() fs.FileMode  { return 0444 }
+func (i memFileInfo) ModTime() time.Time { return time.Time{} }
+func (i memFileInfo) IsDir() bool        { return i.dir }
+func (i memFileInfo) Sys() interface{}   { return nil }
+
+type memDirEntry struct {
+	name string
+	dir  bool
+}
+
+func (e memDirEntry) Name() string { return e.name }
+func (e memDirEntry) IsDir() bool  { return e.dir }
+
+func (e memDirEntry) Type() fs.FileMode {
+	if e.dir {
+		return fs.ModeDir
+	}
+	return 0
+}
+
+func (e memDirEntry) Info() (fs.FileInfo, error) {
+	return memFileInfo{name: e.name, dir: e.dir}, nil
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+func TestMemStoreReadDir(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string // path -> content
+		dir   string
+		want  []string // expected child names, sorted
+	}{
+		{
+			name:  "flat file at root",
+			files: map[string]string{"index.html": "hi"},
+			dir:   "",
+			want:  []string{"index.html"},
+		},
+		{
+			name: "dir with only subdirs, no direct files",
+			files: map[string]string{
+				"static/css/style.css": "css",
+				"static/img/a.png":     "png",
+			},
+			dir:  "static",
+			want: []string{"css", "img"},
+		},
+		{
+			name: "dir with both files and subdirs",
+			files: map[string]string{
+				"static/index.html":    "hi",
+				"static/css/style.css": "css",
+			},
+			dir:  "static",
+			want: []string{"css", "index.html"},
+		},
+		{
+			name: "deeply nested ancestor chain",
+			files: map[string]string{
+				"a/b/c/d.txt": "d",
+			},
+			dir:  "a/b",
+			want: []string{"c"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newMemStore()
+			for name, content := range tt.files {
+				s.add(name, []byte(content))
+			}
+
+			entries, err := s.ReadDir(tt.dir)
+			if err != nil {
+				t.Fatalf("ReadDir(%q): %v", tt.dir, err)
+			}
+			got := make([]string, 0, len(entries))
+			for _, e := range entries {
+				got = append(got, e.Name())
+			}
+			sort.Strings(got)
+			if !equalStrings(got, tt.want) {
+				t.Errorf("ReadDir(%q) = %v, want %v", tt.dir, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMemStoreOpen(t *testing.T) {
+	s := newMemStore()
+	s.add("x.txt", []byte("hello"))
+	s.add("static/css/style.css", []byte("css"))
+
+	t.Run("open a file", func(t *testing.T) {
+		f, err := s.Open("x.txt")
+		if err != nil {
+			t.Fatalf("Open: %v", err)
+		}
+		defer f.Close()
+		b, err := io.ReadAll(f)
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		if string(b) != "hello" {
+			t.Errorf("content = %q, want %q", b, "hello")
+		}
+	})
+
+	t.Run("open a synthesized ancestor dir", func(t *testing.T) {
+		f, err := s.Open("static")
+		if err != nil {
+			t.Fatalf("Open: %v", err)
+		}
+		defer f.Close()
+		info, err := f.Stat()
+		if err != nil {
+			t.Fatalf("Stat: %v", err)
+		}
+		if !info.IsDir() {
+			t.Errorf("IsDir() = false, want true")
+		}
+	})
+
+	t.Run("open missing file", func(t *testing.T) {
+		_, err := s.Open("missing.txt")
+		if !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("err = %v, want fs.ErrNotExist", err)
+		}
+	})
+
+	t.Run("path traversal is clamped to the store root", func(t *testing.T) {
+		f, err := s.Open("../../x.txt")
+		if err != nil {
+			t.Fatalf("Open: %v", err)
+		}
+		defer f.Close()
+		b, err := io.ReadAll(f)
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		if string(b) != "hello" {
+			t.Errorf("content = %q, want %q", b, "hello")
+		}
+	})
+}
+
+func TestZipStoreRoundTrip(t *testing.T) {
+	files := map[string]string{
+		"static/index.html":    "<html></html>",
+		"static/css/style.css": "body{}",
+		"blog/hello.md":        "# hello",
+	}
+
+	buf := &bytes.Buffer{}
+	zw := zip.NewWriter(buf)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatalf("zip create %q: %v", name, err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatalf("zip write %q: %v", name, err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("zip close: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "site.zip")
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatalf("write zip: %v", err)
+	}
+
+	store, err := newZipStore(path)
+	if err != nil {
+		t.Fatalf("newZipStore: %v", err)
+	}
+
+	for name, content := range files {
+		b, err := store.ReadFile(name)
+		if err != nil {
+			t.Fatalf("ReadFile(%q): %v", name, err)
+		}
+		if string(b) != content {
+			t.Errorf("ReadFile(%q) = %q, want %q", name, b, content)
+		}
+	}
+
+	entries, err := store.ReadDir("static")
+	if err != nil {
+		t.Fatalf("ReadDir(static): %v", err)
+	}
+	got := make([]string, 0, len(entries))
+	for _, e := range entries {
+		got = append(got, e.Name())
+	}
+	sort.Strings(got)
+	want := []string{"css", "index.html"}
+	if !equalStrings(got, want) {
+		t.Errorf("ReadDir(static) = %v, want %v", got, want)
+	}
+}
+
+func TestTarGzStoreRoundTrip(t *testing.T) {
+	files := map[string]string{
+		"static/index.html":   "<html></html>",
+		"static/img/a.png":    "png-bytes",
+		"templates/page.html": "{{.Title}}",
+	}
+
+	buf := &bytes.Buffer{}
+	gz := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		hdr := &tar.Header{
+			Name: name,
+			Mode: 0o644,
+			Size: int64(len(content)),
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatalf("tar header %q: %v", name, err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatalf("tar write %q: %v", name, err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("tar close: %v", err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatalf("gzip close: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "site.tar.gz")
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatalf("write tar.gz: %v", err)
+	}
+
+	store, err := newTarGzStore(path)
+	if err != nil {
+		t.Fatalf("newTarGzStore: %v", err)
+	}
+
+	for name, content := range files {
+		b, err := store.ReadFile(name)
+		if err != nil {
+			t.Fatalf("ReadFile(%q): %v", name, err)
+		}
+		if string(b) != content {
+			t.Errorf("ReadFile(%q) = %q, want %q", name, b, content)
+		}
+	}
+
+	entries, err := store.ReadDir("static")
+	if err != nil {
+		t.Fatalf("ReadDir(static): %v", err)
+	}
+	got := make([]string, 0, len(entries))
+	for _, e := range entries {
+		got = append(got, e.Name())
+	}
+	sort.Strings(got)
+	want := []string{"img", "index.html"}
+	if !equalStrings(got, want) {
+		t.Errorf("ReadDir(static) = %v, want %v", got, want)
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// debounce window for rebuilds: editors tend to fire several writes (and
+// renames) for a single save, so we coalesce them instead of rebuilding once
+// per event.
+const rebuildDebounce = 200 * time.Millisecond
+
+// watch observes c.PagesDir, c.TemplatesDir and c.AliasFile on disk and, on
+// any change, rebuilds the site state and swaps it into state. Rebuild
+// errors are logged and the previous good state keeps serving.
+func watch(store Store, c config, state *atomic.Pointer[siteState]) error {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("create watcher: %w", err)
+	}
+
+	dirs := []string{
+		filepath.Join(c.Site, c.PagesDir),
+		filepath.Join(c.Site, c.TemplatesDir),
+		filepath.Dir(filepath.Join(c.Site, c.AliasFile)),
+	}
+	for _, dir := range dirs {
+		if err := w.Add(dir); err != nil {
+			w.Close()
+			return fmt.Errorf("watch %q: %w", dir, err)
+		}
+	}
+
+	go func() {
+		defer w.Close()
+		var timer *time.Timer
+		for {
+			select {
+			case _, ok := <-w.Events:
+				if !ok {
+					return
+				}
+				if timer == nil {
+					timer = time.AfterFunc(rebuildDebounce, func() {
+						rebuild(store, c, state)
+					})
+				} else {
+					timer.Reset(rebuildDebounce)
+				}
+			case err, ok := <-w.Errors:
+				if !ok {
+					return
+				}
+				log.Println("watcher error:", err)
+			}
+		}
+	}()
+	return nil
+}
+
+func rebuild(store Store, c config, state *atomic.Pointer[siteState]) {
+	next, err := buildState(store, c)
+	if err != nil {
+		log.Println("rebuild failed, keeping previous state:", err)
+		return
+	}
+	state.Store(next)
+	log.Println("site state rebuilt")
+
+	if c.HubURL != "" {
+		feedURL := scheme(c.UseTLS) + c.Host + "/feed.xml"
+		if err := pingHub(c.HubURL, feedURL); err != nil {
+			log.Println("websub ping failed:", err)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// pingHub notifies a WebSub/PubSubHubbub hub that feedURL has new content,
+// per https://www.w3.org/TR/websub/#x5-2-publisher-sends-notification. It is
+// a no-op when hubURL is empty, so WebSub stays opt-in via HUB_URL.
+func pingHub(hubURL, feedURL string) error {
+	if hubURL == "" {
+		return nil
+	}
+
+	form := url.Values{
+		"hub.mode": {"publish"},
+		"hub.url":  {feedURL},
+	}
+	resp, err := http.PostForm(hubURL, form)
+	if err != nil {
+		return fmt.Errorf("ping hub %q: %w", hubURL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("ping hub %q: unexpected status %s", hubURL, resp.Status)
+	}
+	return nil
+}